@@ -1,11 +1,12 @@
-package main
+// Package container provides generic container types, currently a red-black tree based
+// ordered map and an interval tree built on top of it.
+package container
 
 import (
 	"fmt"
 	"io"
-	"math"
-	"math/rand"
-	"os"
+
+	"golang.org/x/exp/constraints"
 )
 
 type Direction uint8
@@ -21,18 +22,47 @@ const (
 	Red   Color = true
 )
 
-type Comparable[T any] interface {
+// Ordered is implemented by types that have a total order, following the conventions of
+// net/netip.Addr.Compare and time.Time.Compare: Compare returns a negative number if the
+// receiver sorts before o, a positive number if it sorts after o, and 0 if they're equal.
+type Ordered[T any] interface {
 	Compare(T) int
 }
 
-type RBTree[K Comparable[K], V any] struct {
+// NativeCompare compares two values of a natively ordered type using the built-in operators,
+// for use as a building block when implementing Ordered.
+func NativeCompare[T constraints.Ordered](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// OrderedKey wraps a natively ordered type T so that it implements Ordered[OrderedKey[T]],
+// saving callers from having to hand-write a Compare method for every primitive key type they
+// want to use with RBTree.
+type OrderedKey[T constraints.Ordered] struct {
+	Value T
+}
+
+func (k OrderedKey[T]) Compare(o OrderedKey[T]) int {
+	return NativeCompare(k.Value, o.Value)
+}
+
+// RBTree is a red-black tree, usable as an ordered map from K to V.
+type RBTree[K Ordered[K], V any] struct {
 	Root *RBNode[K, V]
 
-	// AfterMove gets called after a node got moved during a rotation, or after a node got deleted.
+	// AfterMove gets called after a node got moved during a rotation, after a deleted node's
+	// successor got copied into its place, or after a node got deleted.
 	AfterMove func(oldParent, node *RBNode[K, V])
 }
 
-type RBNode[K Comparable[K], V any] struct {
+type RBNode[K Ordered[K], V any] struct {
 	parent   *RBNode[K, V]
 	children [2]*RBNode[K, V]
 	key      K
@@ -40,7 +70,7 @@ type RBNode[K Comparable[K], V any] struct {
 	color    Color
 }
 
-func NewRBNode[K Comparable[K], V any](k K, v V) *RBNode[K, V] {
+func NewRBNode[K Ordered[K], V any](k K, v V) *RBNode[K, V] {
 	return &RBNode[K, V]{
 		key:   k,
 		value: v,
@@ -54,12 +84,12 @@ func (T *RBTree[K, V]) Search(k K) (node *RBNode[K, V], found bool, dir Directio
 
 	x := T.Root
 	for {
-		switch k.Compare(x.key) {
-		case -1:
+		switch c := k.Compare(x.key); {
+		case c < 0:
 			dir = Left
-		case 0:
+		case c == 0:
 			return x, true, 0
-		case 1:
+		default:
 			dir = Right
 		}
 
@@ -71,6 +101,123 @@ func (T *RBTree[K, V]) Search(k K) (node *RBNode[K, V], found bool, dir Directio
 	}
 }
 
+// Len returns the number of nodes in the tree.
+func (T *RBTree[K, V]) Len() int {
+	n := 0
+	T.Iterate(func(*RBNode[K, V]) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// Min returns the node with the smallest key in the tree.
+func (T *RBTree[K, V]) Min() (*RBNode[K, V], bool) {
+	if T.Root == nil {
+		return nil, false
+	}
+	n := T.Root
+	for n.children[Left] != nil {
+		n = n.children[Left]
+	}
+	return n, true
+}
+
+// Max returns the node with the largest key in the tree.
+func (T *RBTree[K, V]) Max() (*RBNode[K, V], bool) {
+	if T.Root == nil {
+		return nil, false
+	}
+	n := T.Root
+	for n.children[Right] != nil {
+		n = n.children[Right]
+	}
+	return n, true
+}
+
+// Successor returns the node whose key immediately follows N's, or nil if N has the largest key
+// in the tree.
+func (T *RBTree[K, V]) Successor(N *RBNode[K, V]) *RBNode[K, V] {
+	if N.children[Right] != nil {
+		n := N.children[Right]
+		for n.children[Left] != nil {
+			n = n.children[Left]
+		}
+		return n
+	}
+
+	n, p := N, N.parent
+	for p != nil && n == p.children[Right] {
+		n, p = p, p.parent
+	}
+	return p
+}
+
+// Predecessor returns the node whose key immediately precedes N's, or nil if N has the smallest
+// key in the tree.
+func (T *RBTree[K, V]) Predecessor(N *RBNode[K, V]) *RBNode[K, V] {
+	if N.children[Left] != nil {
+		n := N.children[Left]
+		for n.children[Right] != nil {
+			n = n.children[Right]
+		}
+		return n
+	}
+
+	n, p := N, N.parent
+	for p != nil && n == p.children[Left] {
+		n, p = p, p.parent
+	}
+	return p
+}
+
+// Iterate performs an in-order traversal of the tree, calling fn for every node. Traversal
+// stops early if fn returns false.
+func (T *RBTree[K, V]) Iterate(fn func(*RBNode[K, V]) bool) {
+	var visit func(n *RBNode[K, V]) bool
+	visit = func(n *RBNode[K, V]) bool {
+		if n == nil {
+			return true
+		}
+		if !visit(n.children[Left]) {
+			return false
+		}
+		if !fn(n) {
+			return false
+		}
+		return visit(n.children[Right])
+	}
+	visit(T.Root)
+}
+
+// Range calls fn for every node with a key in [lo, hi], in ascending order, stopping early if
+// fn returns false.
+func (T *RBTree[K, V]) Range(lo, hi K, fn func(*RBNode[K, V]) bool) {
+	var visit func(n *RBNode[K, V]) bool
+	visit = func(n *RBNode[K, V]) bool {
+		if n == nil {
+			return true
+		}
+		if n.key.Compare(lo) > 0 {
+			if !visit(n.children[Left]) {
+				return false
+			}
+		}
+		if n.key.Compare(lo) >= 0 && n.key.Compare(hi) <= 0 {
+			if !fn(n) {
+				return false
+			}
+		}
+		if n.key.Compare(hi) < 0 {
+			if !visit(n.children[Right]) {
+				return false
+			}
+		}
+		return true
+	}
+	visit(T.Root)
+}
+
 func (T *RBTree[K, V]) rotate(P *RBNode[K, V], dir Direction) *RBNode[K, V] {
 	oldParent := P.parent
 
@@ -130,6 +277,7 @@ func (T *RBTree[K, V]) insert(N *RBNode[K, V], P *RBNode[K, V], dir Direction) {
 	N.children[Right] = nil
 	N.parent = P
 	if P == nil {
+		N.color = Black
 		T.Root = N
 		return
 	}
@@ -171,6 +319,135 @@ func (T *RBTree[K, V]) insert(N *RBNode[K, V], P *RBNode[K, V], dir Direction) {
 			break
 		}
 	}
+
+	T.Root.color = Black
+}
+
+// Delete removes the node with key k from the tree. It reports whether k was present.
+func (T *RBTree[K, V]) Delete(k K) bool {
+	N, ok, _ := T.Search(k)
+	if !ok {
+		return false
+	}
+	T.delete(N)
+	return true
+}
+
+// transplant replaces N, wherever it sits in the tree, with child. It does not touch child's
+// own children.
+func (T *RBTree[K, V]) transplant(N, child *RBNode[K, V]) {
+	P := N.parent
+	if child != nil {
+		child.parent = P
+	}
+	if P == nil {
+		T.Root = child
+	} else if P.children[Left] == N {
+		P.children[Left] = child
+	} else {
+		P.children[Right] = child
+	}
+}
+
+func (T *RBTree[K, V]) delete(N *RBNode[K, V]) {
+	if N.children[Left] != nil && N.children[Right] != nil {
+		// N has two children. Move its in-order successor's key/value into N and delete the
+		// successor instead, which has at most one child.
+		S := N.children[Right]
+		for S.children[Left] != nil {
+			S = S.children[Left]
+		}
+		N.key, N.value = S.key, S.value
+		if T.AfterMove != nil {
+			T.AfterMove(N.parent, N)
+		}
+		N = S
+	}
+
+	var child *RBNode[K, V]
+	if N.children[Left] != nil {
+		child = N.children[Left]
+	} else {
+		child = N.children[Right]
+	}
+
+	P := N.parent
+	var dir Direction
+	if P != nil && P.children[Right] == N {
+		dir = Right
+	}
+
+	T.transplant(N, child)
+	if T.AfterMove != nil {
+		T.AfterMove(P, child)
+	}
+
+	if N.color == Black {
+		if child != nil && child.color == Red {
+			child.color = Black
+		} else {
+			T.deleteFixup(child, P, dir)
+		}
+	}
+}
+
+// deleteFixup restores the red-black invariants after delete() has removed a black node,
+// leaving x (possibly nil) in its place as a child of P in direction dir. This is the standard
+// recolor-and-rotate fixup, bottom-up, needing at most three rotations.
+func (T *RBTree[K, V]) deleteFixup(x, P *RBNode[K, V], dir Direction) {
+	for P != nil && (x == nil || x.color == Black) {
+		// The sibling subtree must be non-nil here: x's subtree is one black node short, and
+		// for that to have been possible, the sibling side must have had enough nodes to match
+		// it before the deletion.
+		sib := P.children[1-dir]
+
+		if sib.color == Red {
+			sib.color = Black
+			P.color = Red
+			T.rotate(P, dir)
+			sib = P.children[1-dir]
+		}
+
+		near := sib.children[dir]
+		far := sib.children[1-dir]
+		nearBlack := near == nil || near.color == Black
+		farBlack := far == nil || far.color == Black
+
+		if nearBlack && farBlack {
+			sib.color = Red
+			x = P
+			P = x.parent
+			if P != nil {
+				dir = Left
+				if P.children[Right] == x {
+					dir = Right
+				}
+			}
+			continue
+		}
+
+		if farBlack {
+			if near != nil {
+				near.color = Black
+			}
+			sib.color = Red
+			T.rotate(sib, 1-dir)
+			sib = P.children[1-dir]
+		}
+
+		sib.color = P.color
+		P.color = Black
+		if far := sib.children[1-dir]; far != nil {
+			far.color = Black
+		}
+		T.rotate(P, dir)
+		x = T.Root
+		break
+	}
+
+	if x != nil {
+		x.color = Black
+	}
 }
 
 func (N *RBNode[K, V]) childDir() Direction {
@@ -225,18 +502,6 @@ func (N *RBNode[K, V]) Dot(w io.Writer, meta func(n *RBNode[K, V]) string) {
 	p("}")
 }
 
-type Int int
-
-func (n Int) Compare(o Int) int {
-	if n < o {
-		return -1
-	} else if n == o {
-		return 0
-	} else {
-		return 1
-	}
-}
-
 type Interval struct {
 	Min, Max int
 }
@@ -262,72 +527,141 @@ func (ival Interval) Compare(oval Interval) int {
 	}
 }
 
+// Contains reports whether the interval contains the point p.
+func (ival Interval) Contains(p int) bool {
+	return p >= ival.Min && p <= ival.Max
+}
+
+// IntervalTree is an RBTree augmented with, at every node, the maximum Max among its own
+// interval and those of its subtree, which is what SearchOverlapping and ContainsPoint use to
+// prune their search.
 type IntervalTree struct {
 	RBTree[Interval, Value]
 }
 
+func (t *IntervalTree) ensureAfterMove() {
+	if t.AfterMove == nil {
+		t.AfterMove = func(oldParent, node *RBNode[Interval, Value]) {
+			// node's own children may have just changed (a rotation), so it needs
+			// recomputing before we walk upward from it. If node is nil (the removed node had
+			// no successor to take its place), there's nothing to recompute below oldParent.
+			t.updateAug(node)
+			start := oldParent
+			if node != nil {
+				start = node.parent
+			}
+			t.updateAugPath(start)
+		}
+	}
+}
+
 func (t *IntervalTree) Insert(min, max int, value string) *RBNode[Interval, Value] {
+	t.ensureAfterMove()
 	n := t.RBTree.Insert(Interval{min, max}, Value{MaxSubtree: max, Value: value})
-	t.updateAug(n.parent)
+	// n may be a pre-existing node whose Value.MaxSubtree just got overwritten with the raw
+	// max we constructed above, ignoring any children it already has, so n itself needs
+	// recomputing too, not just its ancestors.
+	t.updateAugPath(n)
 	return n
 }
 
-func (t *IntervalTree) updateAug(n *RBNode[Interval, Value]) bool {
-	if n == nil {
-		return false
-	}
+// Delete removes n from the tree and fixes up the MaxSubtree augment wherever the tree
+// structure or keys changed as a result.
+func (t *IntervalTree) Delete(n *RBNode[Interval, Value]) {
+	t.ensureAfterMove()
+	t.RBTree.delete(n)
+}
 
-	old := n.value.MaxSubtree
+// SearchOverlapping returns every node whose interval overlaps [min, max]. It uses the
+// MaxSubtree augment to avoid descending into subtrees that cannot possibly contain a match.
+func (t *IntervalTree) SearchOverlapping(min, max int) []*RBNode[Interval, Value] {
+	var out []*RBNode[Interval, Value]
 
-	var vs [3]int
-	for i := range vs[:2] {
-		vs[i] = math.MinInt
-	}
-	vs[2] = n.key.Max
+	var visit func(n *RBNode[Interval, Value])
+	visit = func(n *RBNode[Interval, Value]) {
+		if n == nil {
+			return
+		}
 
-	for i, c := range n.children {
-		if c != nil {
-			vs[i] = c.value.MaxSubtree
+		if left := n.children[Left]; left != nil && left.value.MaxSubtree >= min {
+			visit(left)
 		}
-	}
 
-	max := vs[0]
-	for _, v := range vs[1:] {
-		if v > max {
-			max = v
+		if n.key.Max >= min && n.key.Min <= max {
+			out = append(out, n)
 		}
-	}
 
-	if max != old {
-		n.value.MaxSubtree = max
-		t.updateAug(n.parent)
-		return true
+		// Every interval in the right subtree has a Min >= n.key.Min, so once that's past
+		// max there's nothing left to find on the right either.
+		if n.key.Min <= max {
+			visit(n.children[Right])
+		}
 	}
+	visit(t.Root)
 
-	return false
+	return out
 }
 
-func main() {
-	var t IntervalTree
-	t.AfterMove = func(oldParent, node *RBNode[Interval, Value]) {
-		println("hi")
-		for t.updateAug(oldParent) || t.updateAug(node) {
-			println("nice")
+// ContainsFn reports whether any interval in the tree brackets the target implied by fn: fn(x)
+// compares that target to x, following the same convention as Ordered.Compare (negative if x
+// comes before the target, positive if x comes after it, zero if x is the target itself). Like
+// SearchOverlapping, it relies on MaxSubtree to prune, and additionally stops at the first
+// match instead of collecting every overlap, for O(log n) instead of O(log n + k).
+func (t *IntervalTree) ContainsFn(fn func(x int) int) bool {
+	var visit func(n *RBNode[Interval, Value]) bool
+	visit = func(n *RBNode[Interval, Value]) bool {
+		if n == nil || fn(n.value.MaxSubtree) < 0 {
+			return false
+		}
+
+		if left := n.children[Left]; left != nil && fn(left.value.MaxSubtree) >= 0 {
+			if visit(left) {
+				return true
+			}
+		}
+
+		if fn(n.key.Min) <= 0 && fn(n.key.Max) >= 0 {
+			return true
 		}
-		println("")
-	}
 
-	for i := 0; i < 100; i++ {
-		var min, max int
-		max = rand.Intn(500)
-		for min > max {
-			min = rand.Intn(500)
+		if fn(n.key.Min) > 0 {
+			return false
 		}
 
-		t.Insert(min, max, "")
+		return visit(n.children[Right])
 	}
 
-	t.Root.Dot(os.Stdout, func(n *RBNode[Interval, Value]) string {
-		return fmt.Sprintf("aug = %d", n.value.MaxSubtree)
-	})
+	return visit(t.Root)
+}
+
+// ContainsPoint reports whether any interval in the tree contains p.
+func (t *IntervalTree) ContainsPoint(p int) bool {
+	return t.ContainsFn(func(x int) int { return NativeCompare(x, p) })
+}
+
+// updateAug recomputes n's own MaxSubtree from its key and its children's augmented values. It
+// does not touch n's ancestors; use updateAugPath for that.
+func (t *IntervalTree) updateAug(n *RBNode[Interval, Value]) {
+	if n == nil {
+		return
+	}
+
+	max := n.key.Max
+	for _, c := range n.children {
+		if c != nil && c.value.MaxSubtree > max {
+			max = c.value.MaxSubtree
+		}
+	}
+
+	n.value.MaxSubtree = max
+}
+
+// updateAugPath recomputes the augment of n and every ancestor above it, all the way to the
+// root. It does not stop early when a node's own MaxSubtree comes out unchanged: a rotation can
+// change which children a node has without changing that node's max, so an unchanged value at
+// one level doesn't guarantee the levels above are already correct.
+func (t *IntervalTree) updateAugPath(n *RBNode[Interval, Value]) {
+	for ; n != nil; n = n.parent {
+		t.updateAug(n)
+	}
 }
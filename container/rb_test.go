@@ -0,0 +1,186 @@
+package container
+
+import (
+	"math"
+	"testing"
+)
+
+// checkInvariants verifies that tree still satisfies the red-black properties: the root is
+// black, no red node has a red child, and every root-to-nil path has the same black-height.
+func checkInvariants(t *testing.T, tree *RBTree[OrderedKey[int], int]) {
+	t.Helper()
+
+	if tree.Root != nil && tree.Root.color != Black {
+		t.Fatalf("root is red")
+	}
+
+	var walk func(n *RBNode[OrderedKey[int], int]) int
+	walk = func(n *RBNode[OrderedKey[int], int]) int {
+		if n == nil {
+			return 1
+		}
+
+		if n.color == Red {
+			for _, c := range n.children {
+				if c != nil && c.color == Red {
+					t.Fatalf("red node %v has red child", n.key)
+				}
+			}
+		}
+
+		bhLeft := walk(n.children[Left])
+		bhRight := walk(n.children[Right])
+		if bhLeft != bhRight {
+			t.Fatalf("unequal black-height around key %v: %d vs %d", n.key, bhLeft, bhRight)
+		}
+
+		bh := bhLeft
+		if n.color == Black {
+			bh++
+		}
+		return bh
+	}
+
+	walk(tree.Root)
+}
+
+func FuzzRBTree(f *testing.F) {
+	f.Add([]byte{0, 10, 0, 20, 0, 10, 1, 5, 0, 2, 1, 20})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		var tree RBTree[OrderedKey[int], int]
+		present := map[int]bool{}
+
+		for i := 0; i+1 < len(ops); i += 2 {
+			key := int(ops[i+1])
+			k := OrderedKey[int]{Value: key}
+
+			if ops[i]%2 == 0 {
+				tree.Insert(k, key)
+				present[key] = true
+			} else {
+				ok := tree.Delete(k)
+				if ok != present[key] {
+					t.Fatalf("Delete(%d) = %v, want %v", key, ok, present[key])
+				}
+				present[key] = false
+			}
+
+			checkInvariants(t, &tree)
+		}
+
+		want := 0
+		for key, ok := range present {
+			if !ok {
+				continue
+			}
+			want++
+			if _, found, _ := tree.Search(OrderedKey[int]{Value: key}); !found {
+				t.Fatalf("key %d missing from tree", key)
+			}
+		}
+
+		if got := tree.Len(); got != want {
+			t.Fatalf("Len() = %d, want %d", got, want)
+		}
+	})
+}
+
+// checkAugment verifies that every node's MaxSubtree equals the true maximum Max among its own
+// interval and those of its subtree, and returns that maximum.
+func checkAugment(t *testing.T, n *RBNode[Interval, Value]) int {
+	t.Helper()
+
+	if n == nil {
+		return math.MinInt
+	}
+
+	max := n.key.Max
+	if l := checkAugment(t, n.children[Left]); l > max {
+		max = l
+	}
+	if r := checkAugment(t, n.children[Right]); r > max {
+		max = r
+	}
+
+	if n.value.MaxSubtree != max {
+		t.Fatalf("interval [%d, %d]: MaxSubtree = %d, want %d", n.key.Min, n.key.Max, n.value.MaxSubtree, max)
+	}
+
+	return max
+}
+
+func FuzzIntervalTree(f *testing.F) {
+	f.Add([]byte{0, 10, 20, 0, 15, 25, 0, 30, 40, 1, 0, 0})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		var tree IntervalTree
+		// present tracks the tree's actual contents. It's a set, not a list: inserting a key
+		// that's already present overwrites that node's value rather than adding a sibling, so
+		// a plain slice of every insert would drift from what's really in the tree.
+		present := map[Interval]bool{}
+
+		nodes := func() []*RBNode[Interval, Value] {
+			var ns []*RBNode[Interval, Value]
+			tree.Iterate(func(n *RBNode[Interval, Value]) bool {
+				ns = append(ns, n)
+				return true
+			})
+			return ns
+		}
+
+		for i := 0; i+2 < len(ops); i += 3 {
+			op, a, b := ops[i], int(ops[i+1]), int(ops[i+2])
+
+			ns := nodes()
+			if op%2 == 0 || len(ns) == 0 {
+				min, max := a, b
+				if min > max {
+					min, max = max, min
+				}
+				tree.Insert(min, max, "")
+				present[Interval{min, max}] = true
+			} else {
+				target := ns[a%len(ns)]
+				// Capture the key before deleting: when target has two children, Delete
+				// moves its in-order successor's key/value into target and removes the
+				// successor's node instead, so target.key no longer reads back what we're
+				// actually removing once Delete returns.
+				ival := target.key
+				tree.Delete(target)
+				delete(present, ival)
+			}
+
+			checkAugment(t, tree.Root)
+		}
+
+		for q := 0; q < 256; q += 17 {
+			lo, hi := q, q+10
+
+			want := map[Interval]bool{}
+			wantContains := false
+			for ival := range present {
+				if ival.Max >= lo && ival.Min <= hi {
+					want[ival] = true
+				}
+				if ival.Contains(q) {
+					wantContains = true
+				}
+			}
+
+			got := tree.SearchOverlapping(lo, hi)
+			if len(got) != len(want) {
+				t.Fatalf("SearchOverlapping(%d, %d) = %d results, want %d", lo, hi, len(got), len(want))
+			}
+			for _, n := range got {
+				if !want[n.key] {
+					t.Fatalf("SearchOverlapping(%d, %d) returned unexpected %v", lo, hi, n.key)
+				}
+			}
+
+			if got := tree.ContainsPoint(q); got != wantContains {
+				t.Fatalf("ContainsPoint(%d) = %v, want %v", q, got, wantContains)
+			}
+		}
+	})
+}
@@ -1,11 +1,21 @@
 package theme
 
 import (
+	"bufio"
+	"fmt"
 	"image"
 	"image/color"
+	"io"
 	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/pprof/profile"
 
 	"gioui.org/font"
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
 	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
@@ -17,9 +27,67 @@ import (
 
 // TODO(dh): split FlameGraph into widget state, theme state and style
 
+// Mode selects how a FlameGraph builds its tree from added samples and renders it.
+type Mode int
+
+const (
+	// ModeNormal renders a regular flame graph: each frame's children are the functions it
+	// called.
+	ModeNormal Mode = iota
+	// ModeInverted renders a caller tree: samples are read leaf-first, so each frame's
+	// children are its callers rather than its callees.
+	ModeInverted
+	// ModeDifferential compares two sample sets added via AddSampleWithWeight/LoadDiff, sizing
+	// each frame by the combined magnitude of both and coloring it by the delta between them.
+	ModeDifferential
+)
+
 type FlameGraph struct {
-	Color   func(level, idx int, f Frame) color.NRGBA
+	Color func(level, idx int, f Frame) color.NRGBA
+
+	// Mode selects the kind of tree/graph being built. It must be set before adding any
+	// samples, since ModeInverted affects how AddSample et al. interpret a Sample.
+	Mode Mode
+
+	// SampleType selects which of a pprof profile's sample value types (e.g. "cpu",
+	// "alloc_space", "inuse_objects") ReadPprof reads counts from. If empty, the first sample
+	// value type is used.
+	SampleType string
+
+	// Search dims every frame whose Name doesn't contain it as a substring. It is compared
+	// again on every Layout, so it's fine to update it, e.g. from a search box, between frames.
+	Search string
+
+	// OnFrameClicked, if set, is called with the original Frame whenever the user clicks a frame
+	// with any button other than the secondary one.
+	OnFrameClicked func(f Frame)
+
+	// Matched is set by Layout to the combined NumSamples of the outermost frames matching
+	// Search, so that a stack with matches at several depths is counted once. Only frames that
+	// Layout draws are counted: those outside the zoomed-in subtree, and frames too narrow to be
+	// drawn together with their descendants, are not. It is meaningless while Search is empty.
+	Matched int64
+
 	samples []internalFrame
+	// maxDelta is the largest |Delta| anywhere in samples, computed by Compute for
+	// ModeDifferential's default coloring.
+	maxDelta int64
+	// focus is the frame Layout zooms in on: pxPerSample gets rebased on its NumSamples, and
+	// only its subtree is rendered. nil means the root of samples, i.e. no zoom.
+	focus *internalFrame
+	// hovered is the frame currently under the pointer, if any, and is what Layout draws the
+	// tooltip for. It persists across frames: pointer.Enter only fires once on cross-in, so
+	// clearing it every Layout would make the tooltip flash for a single frame and then vanish
+	// for the rest of the hover. It's only cleared on pointer.Leave/Cancel.
+	hovered *internalFrame
+	// hoverPos is where Layout draws the tooltip for hovered, set alongside it on pointer.Enter.
+	hoverPos image.Point
+}
+
+// ResetZoom clears the current focus, so that Layout goes back to rendering the whole flame
+// graph.
+func (fg *FlameGraph) ResetZoom() {
+	fg.focus = nil
 }
 
 type Sample []Frame
@@ -30,24 +98,48 @@ type Frame struct {
 
 type internalFrame struct {
 	Frame
-	NumSamples int
-	Children   []internalFrame
+	// NumSamples is the combined magnitude of every weight added under this frame, and is what
+	// Layout sizes the frame's box by.
+	NumSamples int64
+	// Delta is the signed sum of every weight added under this frame. Outside of
+	// ModeDifferential, every added weight is positive and Delta == NumSamples.
+	Delta    int64
+	Children []internalFrame
 }
 
 func (fg *FlameGraph) AddSample(sample Sample) {
+	fg.AddSampleWithWeight(sample, 1)
+}
+
+// AddSampleWithWeight adds sample to the flame graph, counting it weight times instead of once.
+// weight may be negative: ModeDifferential uses that to tell a "before" sample (negative
+// weight) from an "after" sample (positive weight) while still sizing frames by the combined
+// magnitude of both. ReadFolded and ReadPprof both funnel through here, as does LoadDiff.
+func (fg *FlameGraph) AddSampleWithWeight(sample Sample, weight int64) {
 	if len(sample) == 0 {
 		return
 	}
 
+	if fg.Mode == ModeInverted {
+		sample = reversed(sample)
+	}
+
+	mag := weight
+	if mag < 0 {
+		mag = -mag
+	}
+
 	toplevel := internalFrame{
 		Frame: Frame{
 			Name: "",
 		},
-		NumSamples: 1,
+		NumSamples: mag,
+		Delta:      weight,
 		Children: []internalFrame{
 			{
 				Frame:      sample[0],
-				NumSamples: 1,
+				NumSamples: mag,
+				Delta:      weight,
 			},
 		},
 	}
@@ -56,7 +148,8 @@ func (fg *FlameGraph) AddSample(sample Sample) {
 	for i := range sample[1:] {
 		child := internalFrame{
 			Frame:      sample[i+1],
-			NumSamples: 1,
+			NumSamples: mag,
+			Delta:      weight,
 		}
 		cur.Children = append(cur.Children, child)
 		cur = &cur.Children[0]
@@ -65,6 +158,137 @@ func (fg *FlameGraph) AddSample(sample Sample) {
 	fg.samples = append(fg.samples, toplevel)
 }
 
+// reversed returns a copy of sample in reverse order, leaving sample itself untouched.
+func reversed(sample Sample) Sample {
+	out := make(Sample, len(sample))
+	for i, f := range sample {
+		out[len(sample)-1-i] = f
+	}
+	return out
+}
+
+// ReadFolded reads Brendan Gregg style "folded stacks" from r: one stack per line, written as
+// semicolon-separated frame names followed by a space and an integer weight, e.g.
+//
+//	main;foo;bar 12
+//
+// Each line adds one sample, weighted by its count, to the flame graph.
+func (fg *FlameGraph) ReadFolded(r io.Reader) error {
+	return fg.readFolded(r, 1)
+}
+
+func (fg *FlameGraph) readFolded(r io.Reader, sign int64) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+
+		sp := strings.LastIndexByte(line, ' ')
+		if sp == -1 {
+			return fmt.Errorf("malformed folded stack %q: missing weight", line)
+		}
+
+		weight, err := strconv.ParseInt(line[sp+1:], 10, 64)
+		if err != nil {
+			return fmt.Errorf("malformed folded stack %q: %w", line, err)
+		}
+
+		names := strings.Split(line[:sp], ";")
+		sample := make(Sample, len(names))
+		for i, name := range names {
+			sample[i] = Frame{Name: name}
+		}
+
+		fg.AddSampleWithWeight(sample, sign*weight)
+	}
+
+	return sc.Err()
+}
+
+// ReadPprof reads a pprof protocol buffer profile from r and adds one weighted sample per
+// profile sample. The value read is picked by SampleType (e.g. "cpu", "alloc_space",
+// "inuse_objects"), or the profile's first sample value type if SampleType is empty. Frames are
+// read from each sample's Location/Line list leaf-to-root and reversed, so that, like with
+// AddSample, the resulting Sample runs from the outermost caller to the innermost callee.
+func (fg *FlameGraph) ReadPprof(r io.Reader) error {
+	return fg.readPprof(r, 1)
+}
+
+func (fg *FlameGraph) readPprof(r io.Reader, sign int64) error {
+	p, err := profile.Parse(r)
+	if err != nil {
+		return err
+	}
+
+	valueIdx := 0
+	if fg.SampleType != "" {
+		valueIdx = -1
+		for i, st := range p.SampleType {
+			if st.Type == fg.SampleType {
+				valueIdx = i
+				break
+			}
+		}
+		if valueIdx == -1 {
+			return fmt.Errorf("sample type %q not found in profile", fg.SampleType)
+		}
+	}
+
+	for _, s := range p.Sample {
+		if valueIdx >= len(s.Value) {
+			continue
+		}
+		weight := s.Value[valueIdx]
+		if weight == 0 {
+			continue
+		}
+
+		var sample Sample
+		for i := len(s.Location) - 1; i >= 0; i-- {
+			loc := s.Location[i]
+			for j := len(loc.Line) - 1; j >= 0; j-- {
+				name := loc.Line[j].Function.Name
+				if name == "" {
+					name = fmt.Sprintf("0x%x", loc.Address)
+				}
+				sample = append(sample, Frame{Name: name})
+			}
+		}
+
+		fg.AddSampleWithWeight(sample, sign*weight)
+	}
+
+	return nil
+}
+
+// LoadDiff reads two profiles, before and after, and adds them as the two sample sets of a
+// differential flame graph: before is added with negative weight and after with positive
+// weight, and Mode is set to ModeDifferential. Each of before and after may independently be a
+// folded-stack text file or a pprof protocol buffer profile; the format is detected by sniffing
+// for pprof's gzip magic bytes.
+func (fg *FlameGraph) LoadDiff(before, after io.Reader) error {
+	fg.Mode = ModeDifferential
+
+	if err := fg.loadSigned(before, -1); err != nil {
+		return fmt.Errorf("reading before profile: %w", err)
+	}
+	if err := fg.loadSigned(after, 1); err != nil {
+		return fmt.Errorf("reading after profile: %w", err)
+	}
+
+	return nil
+}
+
+func (fg *FlameGraph) loadSigned(r io.Reader, sign int64) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return fg.readPprof(br, sign)
+	}
+	return fg.readFolded(br, sign)
+}
+
 func (fg *FlameGraph) Compute() {
 	var merge func(root []internalFrame) []internalFrame
 
@@ -89,6 +313,7 @@ func (fg *FlameGraph) Compute() {
 				}
 				frame.Children = append(frame.Children, next.Children...)
 				frame.NumSamples += next.NumSamples
+				frame.Delta += next.Delta
 				next.NumSamples = 0
 			}
 		}
@@ -114,6 +339,52 @@ func (fg *FlameGraph) Compute() {
 	if len(fg.samples) > 1 {
 		panic("too many top-level samples")
 	}
+
+	fg.maxDelta = 0
+	if fg.Mode == ModeDifferential && len(fg.samples) == 1 {
+		var walk func(f *internalFrame)
+		walk = func(f *internalFrame) {
+			if d := f.Delta; d > fg.maxDelta {
+				fg.maxDelta = d
+			} else if -d > fg.maxDelta {
+				fg.maxDelta = -d
+			}
+			for i := range f.Children {
+				walk(&f.Children[i])
+			}
+		}
+		walk(&fg.samples[0])
+	}
+}
+
+// dim fades c by cutting its alpha to a quarter, used to gray out frames that don't match Search.
+func dim(c color.NRGBA) color.NRGBA {
+	c.A /= 4
+	return c
+}
+
+// diffColor is the default Color for ModeDifferential: red for frames that grew, blue for ones
+// that shrank, saturated in proportion to |delta| relative to maxDelta, the largest delta
+// anywhere in the graph.
+func diffColor(delta, maxDelta int64) color.NRGBA {
+	if maxDelta == 0 {
+		return rgba(0xCCCCCCFF)
+	}
+
+	mag := delta
+	if mag < 0 {
+		mag = -mag
+	}
+	scale := float64(mag) / float64(maxDelta)
+	if scale > 1 {
+		scale = 1
+	}
+
+	v := uint8(255 - scale*155)
+	if delta >= 0 {
+		return color.NRGBA{R: 255, G: v, B: v, A: 255}
+	}
+	return color.NRGBA{R: v, G: v, B: 255, A: 255}
 }
 
 func (fg *FlameGraph) Layout(win *Window, gtx layout.Context) layout.Dimensions {
@@ -125,9 +396,23 @@ func (fg *FlameGraph) Layout(win *Window, gtx layout.Context) layout.Dimensions
 
 	// XXX handle graphs with no samples
 
-	pxPerSample := float64(gtx.Constraints.Min.X) / float64(fg.samples[0].NumSamples)
+	for {
+		ev, ok := gtx.Event(key.Filter{Focus: fg, Name: key.NameEscape})
+		if !ok {
+			break
+		}
+		if _, ok := ev.(key.Event); ok {
+			fg.focus = nil
+		}
+	}
+
+	base := fg.focus
+	if base == nil {
+		base = &fg.samples[0]
+	}
+	pxPerSample := float64(gtx.Constraints.Min.X) / float64(base.NumSamples)
 
-	var do func(level int, startX int, samples []internalFrame)
+	var do func(level int, startX int, frames []*internalFrame, ancestorMatched bool)
 
 	colorFn := fg.Color
 	if colorFn == nil {
@@ -136,16 +421,18 @@ func (fg *FlameGraph) Layout(win *Window, gtx layout.Context) layout.Dimensions
 		}
 	}
 
+	var matched int64
+
 	// Indices tracks the intra-row span index per level. This is useful for color functions that want to discern
 	// neighboring spans.
 	var indices []int
-	do = func(level int, startX int, samples []internalFrame) {
+	do = func(level int, startX int, frames []*internalFrame, ancestorMatched bool) {
 		if len(indices) < level+1 {
 			indices = slices.Grow(indices, level+1-len(indices))[:level+1]
 		}
 
 		x := startX
-		for _, frame := range samples {
+		for _, frame := range frames {
 			width := int(float64(frame.NumSamples) * pxPerSample)
 			if width == 0 {
 				continue
@@ -159,11 +446,64 @@ func (fg *FlameGraph) Layout(win *Window, gtx layout.Context) layout.Dimensions
 				radius = width
 			}
 
+			matches := fg.Search == "" || strings.Contains(frame.Name, fg.Search)
+			// A matching frame's NumSamples already includes every matching descendant's
+			// samples, so only count towards Matched at the shallowest match in each stack;
+			// otherwise a stack with matches at multiple depths would count its samples once
+			// per matching ancestor.
+			if matches && fg.Search != "" && !ancestorMatched {
+				matched += frame.NumSamples
+			}
+
 			func() {
 				y := gtx.Constraints.Min.Y - (height+gtx.Dp(rowSpacing))*(level+1)
 				defer op.Offset(image.Pt(x, y)).Push(gtx.Ops).Pop()
-				shape := clip.UniformRRect(image.Rectangle{Max: image.Pt(width, height)}, radius)
+
+				rect := image.Rectangle{Max: image.Pt(width, height)}
+				area := clip.Rect(rect).Push(gtx.Ops)
+				event.Op(gtx.Ops, frame)
+				for {
+					e, ok := gtx.Event(pointer.Filter{
+						Target: frame,
+						Kinds:  pointer.Press | pointer.Enter | pointer.Leave | pointer.Cancel,
+					})
+					if !ok {
+						break
+					}
+					pe, ok := e.(pointer.Event)
+					if !ok {
+						continue
+					}
+					switch pe.Kind {
+					case pointer.Press:
+						key.FocusOp{Tag: fg}.Add(gtx.Ops)
+						if pe.Buttons.Contain(pointer.ButtonSecondary) {
+							fg.focus = nil
+						} else {
+							fg.focus = frame
+							if fg.OnFrameClicked != nil {
+								fg.OnFrameClicked(frame.Frame)
+							}
+						}
+					case pointer.Enter:
+						fg.hovered = frame
+						fg.hoverPos = image.Pt(x, y)
+					case pointer.Leave, pointer.Cancel:
+						if fg.hovered == frame {
+							fg.hovered = nil
+						}
+					}
+				}
+				area.Pop()
+
+				shape := clip.UniformRRect(rect, radius)
 				c := colorFn(level, *idx, frame.Frame)
+				if fg.Color == nil && fg.Mode == ModeDifferential {
+					c = diffColor(frame.Delta, fg.maxDelta)
+				}
+				if !matches {
+					c = dim(c)
+				}
 				paint.FillShape(gtx.Ops, c, shape.Op(gtx.Ops))
 
 				gtx := gtx
@@ -173,13 +513,53 @@ func (fg *FlameGraph) Layout(win *Window, gtx layout.Context) layout.Dimensions
 				widget.Label{MaxLines: 1, Alignment: text.Middle, HideIfEntirelyTruncated: true}.Layout(gtx, win.Theme.Shaper, font.Font{}, 12, frame.Name, widget.ColorTextMaterial(gtx, rgba(0x000000FF)))
 			}()
 
-			do(level+1, x, frame.Children)
+			do(level+1, x, childPtrs(frame.Children), ancestorMatched || matches)
 			x += width
 
 		}
 	}
 
-	do(0, 0, fg.samples)
+	do(0, 0, []*internalFrame{base}, false)
+
+	fg.Matched = matched
+
+	if fg.hovered != nil {
+		fg.layoutTooltip(win, gtx, base, fg.hovered, fg.hoverPos)
+	}
 
 	return layout.Dimensions{Size: gtx.Constraints.Min}
 }
+
+// childPtrs returns frames as a slice of pointers into the same backing array, so that do can
+// use &internalFrame identity as a stable event tag across frames.
+func childPtrs(frames []internalFrame) []*internalFrame {
+	ptrs := make([]*internalFrame, len(frames))
+	for i := range frames {
+		ptrs[i] = &frames[i]
+	}
+	return ptrs
+}
+
+// layoutTooltip draws the frame name and sample count/percentage of hovered next to pos, which
+// is where pointer.Enter most recently fired for it.
+func (fg *FlameGraph) layoutTooltip(win *Window, gtx layout.Context, base, hovered *internalFrame, pos image.Point) {
+	const pad = 4
+
+	pct := 0.0
+	if base.NumSamples > 0 {
+		pct = float64(hovered.NumSamples) / float64(base.NumSamples) * 100
+	}
+	label := fmt.Sprintf("%s (%d samples, %.2f%%)", hovered.Name, hovered.NumSamples, pct)
+
+	gtx.Constraints.Min = image.Point{}
+	macro := op.Record(gtx.Ops)
+	dims := widget.Label{MaxLines: 1}.Layout(gtx, win.Theme.Shaper, font.Font{}, 12, label, widget.ColorTextMaterial(gtx, rgba(0x000000FF)))
+	call := macro.Stop()
+
+	defer op.Offset(pos).Push(gtx.Ops).Pop()
+	rect := image.Rectangle{Max: image.Pt(dims.Size.X+2*pad, dims.Size.Y+2*pad)}
+	defer clip.Rect(rect).Push(gtx.Ops).Pop()
+	paint.FillShape(gtx.Ops, rgba(0xEEEEEEFF), clip.UniformRRect(rect, 2).Op(gtx.Ops))
+	defer op.Offset(image.Pt(pad, pad)).Push(gtx.Ops).Pop()
+	call.Add(gtx.Ops)
+}
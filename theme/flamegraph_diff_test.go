@@ -0,0 +1,75 @@
+package theme
+
+import "testing"
+
+func TestAddSampleWithWeightNegative(t *testing.T) {
+	var fg FlameGraph
+	fg.Mode = ModeDifferential
+	fg.AddSampleWithWeight(Sample{{Name: "main"}}, -4)
+	fg.Compute()
+
+	root := fg.samples[0]
+	if root.NumSamples != 4 {
+		t.Fatalf("root.NumSamples = %d, want 4 (magnitude, not signed weight)", root.NumSamples)
+	}
+	if root.Delta != -4 {
+		t.Fatalf("root.Delta = %d, want -4", root.Delta)
+	}
+}
+
+func TestComputeDifferentialMerge(t *testing.T) {
+	var fg FlameGraph
+	fg.Mode = ModeDifferential
+	fg.AddSampleWithWeight(Sample{{Name: "main"}, {Name: "foo"}}, -3)
+	fg.AddSampleWithWeight(Sample{{Name: "main"}, {Name: "foo"}}, 10)
+	fg.Compute()
+
+	if len(fg.samples) != 1 {
+		t.Fatalf("got %d top-level samples, want 1", len(fg.samples))
+	}
+
+	main := fg.samples[0].Children[0]
+	if main.NumSamples != 13 {
+		t.Fatalf("main.NumSamples = %d, want 13", main.NumSamples)
+	}
+	if main.Delta != 7 {
+		t.Fatalf("main.Delta = %d, want 7", main.Delta)
+	}
+
+	foo := main.Children[0]
+	if foo.NumSamples != 13 || foo.Delta != 7 {
+		t.Fatalf("foo = %+v, want NumSamples=13 Delta=7", foo)
+	}
+
+	if fg.maxDelta != 7 {
+		t.Fatalf("maxDelta = %d, want 7", fg.maxDelta)
+	}
+}
+
+func TestDiffColor(t *testing.T) {
+	gray := diffColor(0, 0)
+	if gray.R != gray.G || gray.G != gray.B {
+		t.Fatalf("diffColor(0, 0) = %+v, want a neutral gray", gray)
+	}
+
+	red := diffColor(10, 10)
+	if !(red.R == 255 && red.G < 255 && red.G == red.B) {
+		t.Fatalf("diffColor(10, 10) = %+v, want fully saturated red", red)
+	}
+
+	blue := diffColor(-10, 10)
+	if !(blue.B == 255 && blue.R < 255 && blue.R == blue.G) {
+		t.Fatalf("diffColor(-10, 10) = %+v, want fully saturated blue", blue)
+	}
+
+	// A delta larger in magnitude than maxDelta must still clamp instead of overflowing v.
+	clamped := diffColor(1000, 10)
+	if clamped != red {
+		t.Fatalf("diffColor(1000, 10) = %+v, want the same fully saturated red as diffColor(10, 10) = %+v", clamped, red)
+	}
+
+	partial := diffColor(5, 10)
+	if partial.R != 255 || partial.G != partial.B || partial.G <= red.G || partial.G >= gray.G {
+		t.Fatalf("diffColor(5, 10) = %+v, want a red partway between diffColor(0,0)=%+v and diffColor(10,10)=%+v", partial, gray, red)
+	}
+}
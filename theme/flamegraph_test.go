@@ -0,0 +1,151 @@
+package theme
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestReadFolded(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{
+			name:  "single stack",
+			input: "main;foo;bar 12\n",
+		},
+		{
+			name:  "multiple stacks sharing a prefix",
+			input: "main;foo;bar 12\nmain;foo;baz 3\n",
+		},
+		{
+			name:  "blank lines are ignored",
+			input: "\n  \nmain;foo 1\n\n",
+		},
+		{
+			name:    "missing weight",
+			input:   "main;foo;bar\n",
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric weight",
+			input:   "main;foo;bar abc\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fg FlameGraph
+			err := fg.ReadFolded(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadFolded(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadFoldedWeights(t *testing.T) {
+	var fg FlameGraph
+	if err := fg.ReadFolded(strings.NewReader("main;foo;bar 12\nmain;foo;baz 3\n")); err != nil {
+		t.Fatalf("ReadFolded: %v", err)
+	}
+	fg.Compute()
+
+	if len(fg.samples) != 1 {
+		t.Fatalf("got %d top-level samples, want 1", len(fg.samples))
+	}
+	root := fg.samples[0]
+	if root.NumSamples != 15 {
+		t.Fatalf("root.NumSamples = %d, want 15", root.NumSamples)
+	}
+
+	main := root.Children[0]
+	if main.Name != "main" || main.NumSamples != 15 {
+		t.Fatalf("main = %+v, want Name=main NumSamples=15", main)
+	}
+
+	foo := main.Children[0]
+	if foo.Name != "foo" || foo.NumSamples != 15 || len(foo.Children) != 2 {
+		t.Fatalf("foo = %+v, want Name=foo NumSamples=15 with 2 children", foo)
+	}
+}
+
+// buildProfile builds a single-location pprof profile for one call stack, given as frames
+// ordered outermost (root) first. pprof itself stores a location's Lines leaf-first, which is
+// what ReadPprof's reversal expects, so this builds them in that order.
+func buildProfile(t *testing.T, sampleType string, frames []string, value int64) *bytes.Buffer {
+	t.Helper()
+
+	var funcs []*profile.Function
+	var lines []profile.Line
+	for i := len(frames) - 1; i >= 0; i-- {
+		fn := &profile.Function{ID: uint64(len(frames) - i), Name: frames[i]}
+		funcs = append(funcs, fn)
+		lines = append(lines, profile.Line{Function: fn})
+	}
+	loc := &profile.Location{ID: 1, Line: lines}
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: sampleType, Unit: "count"}},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{loc}, Value: []int64{value}},
+		},
+		Location: []*profile.Location{loc},
+		Function: funcs,
+	}
+
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return &buf
+}
+
+func TestReadPprof(t *testing.T) {
+	buf := buildProfile(t, "cpu", []string{"main", "foo", "bar"}, 7)
+
+	var fg FlameGraph
+	if err := fg.ReadPprof(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("ReadPprof: %v", err)
+	}
+	fg.Compute()
+
+	if len(fg.samples) != 1 {
+		t.Fatalf("got %d top-level samples, want 1", len(fg.samples))
+	}
+	main := fg.samples[0].Children[0]
+	if main.Name != "main" || main.NumSamples != 7 {
+		t.Fatalf("main = %+v, want Name=main NumSamples=7", main)
+	}
+	bar := main.Children[0].Children[0]
+	if bar.Name != "bar" || bar.NumSamples != 7 {
+		t.Fatalf("bar = %+v, want Name=bar NumSamples=7", bar)
+	}
+}
+
+func TestReadPprofSampleType(t *testing.T) {
+	buf := buildProfile(t, "alloc_space", []string{"main"}, 5)
+
+	var fg FlameGraph
+	fg.SampleType = "does_not_exist"
+	if err := fg.ReadPprof(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Fatalf("ReadPprof with unknown SampleType: got nil error, want one")
+	}
+}
+
+func TestReadPprofZeroWeightSkipped(t *testing.T) {
+	buf := buildProfile(t, "cpu", []string{"main"}, 0)
+
+	var fg FlameGraph
+	if err := fg.ReadPprof(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("ReadPprof: %v", err)
+	}
+	if len(fg.samples) != 0 {
+		t.Fatalf("got %d samples for a zero-weight profile sample, want 0", len(fg.samples))
+	}
+}